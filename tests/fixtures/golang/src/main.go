@@ -1,19 +1,193 @@
 package main
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+)
+
 const MaxRetries = 3
 
 var globalState string
 
-func process(data string) string { return data }
+func process(cfg *Config, data string) string {
+	loggerOf(cfg).Debug("process", "data", data)
+	return data
+}
+
+// processWithRetry runs process under cfg's retry policy. process never
+// returns an error, so it runs at most once; the returned error is non-nil
+// only when ctx is done before the policy's concurrency slot is acquired.
+func processWithRetry(ctx context.Context, cfg *Config, data string) (string, error) {
+	var out string
+	err := cfg.doRetry(ctx, func() error {
+		out = process(cfg, data)
+		return nil
+	})
+	return out, err
+}
 
 type Config struct {
 	Host string
 	Port int
+
+	// Stderr, if set, receives diagnostic output instead of the
+	// process-wide stderr. Defaults to os.Stderr when nil.
+	Stderr io.Writer
+	// Logger receives structured diagnostics. Defaults to slog.Default()
+	// when nil.
+	Logger *slog.Logger
+
+	// Retry configures retry/backoff behavior for processWithRetry and
+	// SingleHandler.Dispatch. Calls to Handler.Handle are not wrapped.
+	// Defaults to a RetryPolicy with package defaults when nil.
+	Retry *RetryPolicy
+
+	defaultRetryOnce sync.Once
+	defaultRetry     *RetryPolicy
+}
+
+func (c *Config) Validate() bool {
+	if len(c.Host) == 0 {
+		return false
+	}
+	if c.Stderr != nil && isNilWriter(c.Stderr) {
+		return false
+	}
+	return true
+}
+
+// isNilWriter reports whether w is a non-nil io.Writer interface wrapping a
+// nil pointer, which would panic on first Write.
+func isNilWriter(w io.Writer) bool {
+	v := reflect.ValueOf(w)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// stderrOf falls back to os.Stderr when cfg.Stderr is unset or a nil-wrapping
+// writer, so callers don't have to validate cfg before using it.
+func stderrOf(cfg *Config) io.Writer {
+	if cfg != nil && cfg.Stderr != nil && !isNilWriter(cfg.Stderr) {
+		return cfg.Stderr
+	}
+	return os.Stderr
+}
+
+func loggerOf(cfg *Config) *slog.Logger {
+	if cfg != nil && cfg.Logger != nil {
+		return cfg.Logger
+	}
+	return slog.Default()
+}
+
+// retryPolicy returns cfg.Retry, falling back to a cfg-cached RetryPolicy
+// with package defaults when cfg.Retry is nil. The default is cached rather
+// than built fresh per call because RetryPolicy.sem — the RLIMIT_NOFILE-
+// derived concurrency cap — only bounds concurrent retries if the same
+// policy instance is reused across calls.
+func (c *Config) retryPolicy() *RetryPolicy {
+	if c == nil {
+		return &RetryPolicy{}
+	}
+	if c.Retry != nil {
+		return c.Retry
+	}
+	c.defaultRetryOnce.Do(func() { c.defaultRetry = &RetryPolicy{} })
+	return c.defaultRetry
 }
 
-func (c *Config) Validate() bool { return len(c.Host) > 0 }
+// doRetry runs fn under cfg's retry policy, reporting retry exhaustion
+// through cfg's writer/logger rather than just returning the final error.
+// A ctx cancellation/deadline is reported as-is, not logged as exhaustion,
+// since Do may have returned ctx.Err() after few or even zero attempts.
+func (c *Config) doRetry(ctx context.Context, fn func() error) error {
+	policy := c.retryPolicy()
+	err := policy.Do(ctx, fn)
+	if err != nil && policy.classify(err) && ctx.Err() == nil {
+		fmt.Fprintf(stderrOf(c), "retry: max attempts (%d) exhausted: %v\n", policy.maxRetries(), err)
+		loggerOf(c).Warn("retry attempts exhausted", "max_retries", policy.maxRetries(), "error", err)
+	}
+	return err
+}
 
 type Handler interface {
 	Handle(msg string)
 	Name() string
 }
+
+// SingleHandler is a strongly-typed alternative to Handler: it carries its
+// own Req/Resp constructors and hands out pooled, zeroable instances instead
+// of forcing callers to marshal through string.
+type SingleHandler[Req, Resp any] struct {
+	name    string
+	cfg     *Config
+	fn      func(ctx context.Context, req Req) (Resp, error)
+	newReq  func() Req
+	newResp func() Resp
+
+	reqPool  sync.Pool
+	respPool sync.Pool
+}
+
+func NewSingleHandler[Req, Resp any](cfg *Config, name string, newReq func() Req, newResp func() Resp, fn func(ctx context.Context, req Req) (Resp, error)) *SingleHandler[Req, Resp] {
+	h := &SingleHandler[Req, Resp]{name: name, cfg: cfg, fn: fn, newReq: newReq, newResp: newResp}
+	h.reqPool.New = func() any { return newReq() }
+	h.respPool.New = func() any { return newResp() }
+	return h
+}
+
+func (h *SingleHandler[Req, Resp]) Name() string { return h.name }
+
+// resettable is implemented by Req/Resp types that want pooled instances
+// zeroed before reuse; types that don't implement it get whatever the
+// previous caller left behind.
+type resettable interface{ Reset() }
+
+func resetIfResettable[T any](v T) T {
+	if r, ok := any(v).(resettable); ok {
+		r.Reset()
+	}
+	return v
+}
+
+// NewRequest returns a pooled Req ready for reuse by the caller.
+func (h *SingleHandler[Req, Resp]) NewRequest() Req {
+	return resetIfResettable(h.reqPool.Get().(Req))
+}
+
+// NewResponse returns a pooled Resp ready for reuse by the caller.
+func (h *SingleHandler[Req, Resp]) NewResponse() Resp {
+	return resetIfResettable(h.respPool.Get().(Resp))
+}
+
+// Release returns resp to the handler's pool. Callers that obtained resp
+// from Dispatch must call Release once they're done with it; Dispatch
+// itself can't recycle resp automatically since the caller is still holding
+// the value it returned.
+func (h *SingleHandler[Req, Resp]) Release(resp Resp) { h.respPool.Put(resp) }
+
+// Dispatch routes req through the handler's typed function, recycling req
+// back into its pool once the call completes (resp is the caller's
+// responsibility; see Release). Handler panics are recovered and reported
+// through the handler's configured writer/logger rather than crashing the
+// process.
+func (h *SingleHandler[Req, Resp]) Dispatch(ctx context.Context, req Req) (resp Resp, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(stderrOf(h.cfg), "handler %q panic recovered: %v\n", h.name, r)
+			loggerOf(h.cfg).Error("handler panic recovered", "handler", h.name, "panic", r)
+			err = fmt.Errorf("handler %q panic: %v", h.name, r)
+		}
+		h.reqPool.Put(req)
+	}()
+	err = h.cfg.doRetry(ctx, func() error {
+		var callErr error
+		resp, callErr = h.fn(ctx, req)
+		return callErr
+	})
+	return resp, err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// defaultRetryConcurrencyCap is the fallback ceiling for concurrent
+// in-flight retries used whenever a platform-specific resource limit can't
+// be determined.
+const defaultRetryConcurrencyCap = 256
+
+// RetryPolicy wraps calls made through Do (processWithRetry and
+// SingleHandler.Dispatch use it; Handler.Handle calls do not go through it)
+// with exponential backoff, jitter, a per-call deadline via context.Context,
+// and a cap on concurrent in-flight retries derived from available file
+// descriptors (see retryConcurrencyCap).
+type RetryPolicy struct {
+	// MaxRetries caps the number of attempts; defaults to MaxRetries (3)
+	// when zero.
+	MaxRetries int
+	// BaseDelay is the initial backoff delay; defaults to 50ms when zero.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay before jitter is added, so a single
+	// wait can reach 1.5x MaxDelay; defaults to 5s when zero.
+	MaxDelay time.Duration
+	// Classify reports whether an error is retryable; defaults to
+	// retrying every non-nil error when nil.
+	Classify func(error) bool
+	// MaxConcurrent caps the number of in-flight retrying calls; defaults
+	// to a platform-derived safe value (see retryConcurrencyCap) when
+	// zero.
+	MaxConcurrent int
+
+	initSem sync.Once
+	sem     chan struct{}
+}
+
+func (p *RetryPolicy) maxRetries() int {
+	if p.MaxRetries > 0 {
+		return p.MaxRetries
+	}
+	return MaxRetries
+}
+
+func (p *RetryPolicy) baseDelay() time.Duration {
+	if p.BaseDelay > 0 {
+		return p.BaseDelay
+	}
+	return 50 * time.Millisecond
+}
+
+func (p *RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay > 0 {
+		return p.MaxDelay
+	}
+	return 5 * time.Second
+}
+
+func (p *RetryPolicy) classify(err error) bool {
+	if p.Classify != nil {
+		return p.Classify(err)
+	}
+	return err != nil
+}
+
+func (p *RetryPolicy) semaphore() chan struct{} {
+	p.initSem.Do(func() {
+		n := p.MaxConcurrent
+		if n <= 0 {
+			n = retryConcurrencyCap()
+		}
+		p.sem = make(chan struct{}, n)
+	})
+	return p.sem
+}
+
+// Do invokes fn, retrying with exponential backoff and jitter while ctx
+// remains valid, the attempt cap hasn't been reached, and Classify reports
+// the error as retryable. It blocks until a concurrency slot is available so
+// in-flight retries never exceed MaxConcurrent (or the platform-derived
+// default).
+func (p *RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	sem := p.semaphore()
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-sem }()
+
+	var err error
+	for attempt := 0; attempt < p.maxRetries(); attempt++ {
+		if err = fn(); err == nil || !p.classify(err) {
+			return err
+		}
+		if attempt == p.maxRetries()-1 {
+			break
+		}
+		select {
+		case <-time.After(p.backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// backoff computes an exponential delay for attempt (0-indexed), capped at
+// MaxDelay, with up to 50% jitter added to avoid synchronized retries.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.baseDelay()) * math.Pow(2, float64(attempt))
+	if max := float64(p.maxDelay()); d > max {
+		d = max
+	}
+	return time.Duration(d + d*0.5*rand.Float64())
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// retryConcurrencyCap derives a safe ceiling for concurrent in-flight
+// retries from RLIMIT_NOFILE, so a burst of retries can never starve the
+// process of file descriptors. It allows at most a quarter of the soft
+// limit, falling back to defaultRetryConcurrencyCap if the limit can't be
+// read.
+func retryConcurrencyCap() int {
+	var rlimit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlimit); err != nil {
+		return defaultRetryConcurrencyCap
+	}
+	limit := int(rlimit.Cur / 4)
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
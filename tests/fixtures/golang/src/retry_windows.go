@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+// retryConcurrencyCap returns the static fallback used on Windows, where
+// RLIMIT_NOFILE has no equivalent. Set RetryPolicy.MaxConcurrent for a
+// different ceiling.
+func retryConcurrencyCap() int {
+	return defaultRetryConcurrencyCap
+}